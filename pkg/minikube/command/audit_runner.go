@@ -0,0 +1,144 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// AuditRunner wraps a CommandRunner, writing one line to w for every
+// command run through it, recording whether it succeeded.
+type AuditRunner struct {
+	inner CommandRunner
+	w     io.Writer
+}
+
+// NewAuditRunner returns a CommandRunner that logs every command it
+// runs to w.
+func NewAuditRunner(inner CommandRunner, w io.Writer) *AuditRunner {
+	return &AuditRunner{inner: inner, w: w}
+}
+
+// log writes a single audit line for cmd. CommandError.Error() embeds
+// literal newlines (stdout/stderr), so the status is quoted to keep
+// each entry exactly one line no matter what the command produced.
+func (a *AuditRunner) log(cmd string, err error) {
+	status := "ok"
+	if err != nil {
+		status = strconv.Quote(err.Error())
+	}
+	fmt.Fprintf(a.w, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), strconv.Quote(cmd), status)
+}
+
+// Run implements CommandRunner.
+func (a *AuditRunner) Run(cmd string) error {
+	err := a.inner.Run(cmd)
+	a.log(cmd, err)
+	return err
+}
+
+// RunContext implements CommandRunner.
+func (a *AuditRunner) RunContext(ctx context.Context, cmd string) error {
+	err := a.inner.RunContext(ctx, cmd)
+	a.log(cmd, err)
+	return err
+}
+
+// CombinedOutput implements CommandRunner.
+func (a *AuditRunner) CombinedOutput(cmd string) (string, error) {
+	out, err := a.inner.CombinedOutput(cmd)
+	a.log(cmd, err)
+	return out, err
+}
+
+// Copy implements CommandRunner.
+func (a *AuditRunner) Copy(f assets.CopyableFile) error {
+	err := a.inner.Copy(f)
+	a.log(fmt.Sprintf("copy %s -> %s", f.GetTargetName(), path.Join(f.GetTargetDir(), f.GetTargetName())), err)
+	return err
+}
+
+// Remove implements CommandRunner.
+func (a *AuditRunner) Remove(f assets.CopyableFile) error {
+	err := a.inner.Remove(f)
+	a.log(fmt.Sprintf("remove %s", path.Join(f.GetTargetDir(), f.GetTargetName())), err)
+	return err
+}
+
+var _ ExtendedRunner = (*AuditRunner)(nil)
+
+// RunWithExitCode implements ExtendedRunner, logging it like Run.
+func (a *AuditRunner) RunWithExitCode(cmd string) (stdout, stderr string, code int, err error) {
+	e, ok := a.inner.(ExtendedRunner)
+	if !ok {
+		return "", "", 0, fmt.Errorf("underlying runner does not support RunWithExitCode")
+	}
+	stdout, stderr, code, err = e.RunWithExitCode(cmd)
+	a.log(cmd, err)
+	return stdout, stderr, code, err
+}
+
+// Start implements ExtendedRunner, logging that cmd was started.
+func (a *AuditRunner) Start(cmd string) (*RemoteCmd, error) {
+	e, ok := a.inner.(ExtendedRunner)
+	if !ok {
+		return nil, fmt.Errorf("underlying runner does not support Start")
+	}
+	rc, err := e.Start(cmd)
+	a.log(fmt.Sprintf("start %s", cmd), err)
+	return rc, err
+}
+
+// Shell implements ExtendedRunner. An interactive shell isn't logged as
+// a single audit entry the way a command is: it has no one-shot result
+// to record success or failure for, so it's passed straight through.
+func (a *AuditRunner) Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error {
+	e, ok := a.inner.(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support Shell")
+	}
+	return e.Shell(stdin, stdout, stderr, term, w, h)
+}
+
+// CopyMany implements ExtendedRunner, logging the batch like Copy.
+func (a *AuditRunner) CopyMany(files []assets.CopyableFile, concurrency int) error {
+	e, ok := a.inner.(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support CopyMany")
+	}
+	err := e.CopyMany(files, concurrency)
+	a.log(fmt.Sprintf("copy %d files", len(files)), err)
+	return err
+}
+
+// CopyDir implements ExtendedRunner, logging it like Copy.
+func (a *AuditRunner) CopyDir(localDir, remoteDir string) error {
+	e, ok := a.inner.(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support CopyDir")
+	}
+	err := e.CopyDir(localDir, remoteDir)
+	a.log(fmt.Sprintf("copy %s -> %s", localDir, remoteDir), err)
+	return err
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// fakeCopyableFile is a minimal assets.CopyableFile stub for exercising
+// decorators without a real asset. name defaults to "addon.yaml" when unset.
+type fakeCopyableFile struct {
+	name string
+}
+
+func (f fakeCopyableFile) targetName() string {
+	if f.name == "" {
+		return "addon.yaml"
+	}
+	return f.name
+}
+
+func (fakeCopyableFile) Read([]byte) (int, error)       { return 0, io.EOF }
+func (fakeCopyableFile) Close() error                   { return nil }
+func (fakeCopyableFile) GetLength() int                 { return 0 }
+func (f fakeCopyableFile) GetAssetName() string         { return f.targetName() }
+func (fakeCopyableFile) GetTargetDir() string           { return "/etc/kubernetes/addons" }
+func (f fakeCopyableFile) GetTargetName() string        { return f.targetName() }
+func (fakeCopyableFile) GetPermissions() string         { return "0644" }
+func (fakeCopyableFile) GetModTime() (time.Time, error) { return time.Time{}, nil }
+
+func TestAuditRunnerLogIsOneLinePerCommand(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeRunner{runErr: &CommandError{
+		ExitCode: 1,
+		Stdout:   "line one\nline two",
+		Stderr:   "uh oh\nmore detail",
+	}}, &buf)
+
+	if err := a.Run("kubeadm init"); err == nil {
+		t.Fatal("expected the inner error to surface")
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one audit line for one command, got %d: %q", len(lines), buf.String())
+	}
+}
+
+func TestAuditRunnerLogRecordsSuccess(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeRunner{}, &buf)
+
+	if err := a.Run("true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "ok") {
+		t.Errorf("expected audit log to record success, got: %q", buf.String())
+	}
+}
+
+func TestAuditRunnerLogsCopy(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeRunner{}, &buf)
+
+	if err := a.Copy(fakeCopyableFile{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected Copy to write an audit line, got none")
+	}
+}
+
+func TestAuditRunnerLogsRemove(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeRunner{}, &buf)
+
+	if err := a.Remove(fakeCopyableFile{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected Remove to write an audit line, got none")
+	}
+}
+
+func TestAuditRunnerLogsExtendedMethods(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeExtendedRunner{exitCode: 7}, &buf)
+
+	if _, _, code, err := a.RunWithExitCode("true"); err != nil || code != 7 {
+		t.Errorf("RunWithExitCode = (_, _, %d, %v), want (_, _, 7, nil)", code, err)
+	}
+	if _, err := a.Start("true"); err != nil {
+		t.Errorf("Start: %v", err)
+	}
+	if err := a.CopyMany([]assets.CopyableFile{fakeCopyableFile{}}, 1); err != nil {
+		t.Errorf("CopyMany: %v", err)
+	}
+	if err := a.CopyDir("/local", "/remote"); err != nil {
+		t.Errorf("CopyDir: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected one audit line per extended call, got %d: %q", len(lines), buf.String())
+	}
+}
+
+func TestAuditRunnerShellPassesThroughWithoutLogging(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeExtendedRunner{}, &buf)
+
+	if err := a.Shell(strings.NewReader(""), io.Discard, io.Discard, "", 0, 0); err != nil {
+		t.Fatalf("Shell: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected Shell not to write an audit line, got: %q", buf.String())
+	}
+}
+
+func TestAuditRunnerExtendedMethodsErrorOnNarrowInner(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditRunner(&fakeRunner{}, &buf)
+
+	if _, _, _, err := a.RunWithExitCode("true"); err == nil {
+		t.Error("RunWithExitCode: expected an error when the wrapped runner doesn't implement ExtendedRunner")
+	}
+	if err := a.Shell(strings.NewReader(""), io.Discard, io.Discard, "", 0, 0); err == nil {
+		t.Error("Shell: expected an error when the wrapped runner doesn't implement ExtendedRunner")
+	}
+}
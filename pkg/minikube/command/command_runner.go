@@ -0,0 +1,79 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"io"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// CommandRunner represents an interface to run commands on, and copy
+// files to, a cluster host. Implementations include SSHRunner (for VM
+// and container drivers) and ExecRunner (for the "none" driver).
+type CommandRunner interface {
+	// Run starts a command on the target and waits for it to return.
+	Run(cmd string) error
+
+	// RunContext starts a command on the target and waits for it to
+	// return, aborting early if ctx is cancelled.
+	RunContext(ctx context.Context, cmd string) error
+
+	// CombinedOutput runs a command on the target and returns its
+	// combined standard output and standard error.
+	CombinedOutput(cmd string) (string, error)
+
+	// Copy copies a file to the target.
+	Copy(assets.CopyableFile) error
+
+	// Remove removes a file from the target.
+	Remove(assets.CopyableFile) error
+}
+
+// ExtendedRunner is the superset of CommandRunner implemented by
+// SSHRunner: exit codes for preflight checks, an interactive shell,
+// backgrounded commands, and batched or recursive copies. It is not
+// part of CommandRunner itself because ExecRunner (the "none" driver)
+// doesn't need an SSH-flavored shell or sftp-backed directory copy.
+//
+// RetryRunner and TimeoutRunner implement ExtendedRunner too, forwarding
+// to the runner they wrap, so composing either of them with an SSHRunner
+// doesn't strand a caller on the narrow CommandRunner surface. Callers
+// that need these methods on an arbitrary CommandRunner should type-assert
+// to ExtendedRunner and handle the case where it's not implemented.
+type ExtendedRunner interface {
+	CommandRunner
+
+	// RunWithExitCode runs cmd and returns its stdout, stderr, and exit
+	// code in addition to any transport-level error.
+	RunWithExitCode(cmd string) (stdout, stderr string, code int, err error)
+
+	// Start starts cmd in the background and returns a handle to it.
+	Start(cmd string) (*RemoteCmd, error)
+
+	// Shell runs an interactive shell, proxying stdin/stdout/stderr. w
+	// and h give the initial terminal size.
+	Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error
+
+	// CopyMany copies files to the target concurrently, aggregating
+	// per-file and per-directory failures instead of aborting the batch.
+	CopyMany(files []assets.CopyableFile, concurrency int) error
+
+	// CopyDir copies a local directory tree to the target.
+	CopyDir(localDir, remoteDir string) error
+}
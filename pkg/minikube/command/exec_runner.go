@@ -0,0 +1,146 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// ExecRunner runs commands locally using os/exec.
+//
+// It implements the CommandRunner interface.
+type ExecRunner struct{}
+
+// NewExecRunner returns a new ExecRunner that will run commands
+// through os/exec.
+func NewExecRunner() *ExecRunner {
+	return &ExecRunner{}
+}
+
+// Run starts a command locally and waits for it to return.
+func (*ExecRunner) Run(cmd string) error {
+	glog.Infof("Run: %s", cmd)
+	c := exec.Command("/bin/bash", "-c", cmd)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return errors.Wrapf(err, "running command: %s", cmd)
+	}
+	return nil
+}
+
+// RunContext starts a command locally and waits for it to return,
+// killing the process if ctx is cancelled first.
+func (*ExecRunner) RunContext(ctx context.Context, cmd string) error {
+	glog.Infof("Run: %s", cmd)
+	c := exec.CommandContext(ctx, "/bin/bash", "-c", cmd)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return errors.Wrapf(err, "running command: %s", cmd)
+	}
+	return nil
+}
+
+// CombinedOutput runs the command locally and returns its combined
+// standard output and standard error.
+func (*ExecRunner) CombinedOutput(cmd string) (string, error) {
+	glog.Infoln("Run with output:", cmd)
+	c := exec.Command("/bin/bash", "-c", cmd)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return string(out), errors.Wrapf(err, "running command: %s\noutput: %s", cmd, out)
+	}
+	return string(out), nil
+}
+
+// Copy copies a file to the target path on the local filesystem.
+func (*ExecRunner) Copy(f assets.CopyableFile) error {
+	dst := filepath.Join(f.GetTargetDir(), f.GetTargetName())
+	glog.Infof("Copying %d bytes to %s", f.GetLength(), dst)
+
+	if err := os.MkdirAll(f.GetTargetDir(), 0777); err != nil {
+		return errors.Wrapf(err, "mkdir %s", f.GetTargetDir())
+	}
+	perm, err := strconv.ParseUint(f.GetPermissions(), 8, 32)
+	if err != nil {
+		return errors.Wrapf(err, "parsing permissions %q", f.GetPermissions())
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(perm))
+	if err != nil {
+		return errors.Wrapf(err, "open %s", dst)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, f); err != nil {
+		return errors.Wrap(err, "io.Copy")
+	}
+	return nil
+}
+
+// Remove removes a file from the local filesystem.
+func (*ExecRunner) Remove(f assets.CopyableFile) error {
+	dst := filepath.Join(f.GetTargetDir(), f.GetTargetName())
+	glog.Infof("rm: %s", dst)
+	return os.Remove(dst)
+}
+
+// Shell attaches an interactive local shell, wiring stdin/stdout/stderr
+// straight through. w and h are accepted to satisfy command.Interactive
+// but are otherwise unused: os/exec inherits the caller's own
+// controlling terminal rather than allocating a new pty.
+//
+// It implements command.Interactive.
+func (*ExecRunner) Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error {
+	sh := os.Getenv("SHELL")
+	if sh == "" {
+		sh = "/bin/bash"
+	}
+	glog.Infof("Shell: %s", sh)
+	c := exec.Command(sh)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	// An empty term means the caller didn't ask for a specific value, not
+	// "blank TERM" — leave the inherited environment's TERM alone instead
+	// of overriding it, dropping any existing entry first so the child
+	// doesn't end up with two conflicting TERM= values.
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "TERM=") {
+			env = append(env, kv)
+		}
+	}
+	if term == "" {
+		term = os.Getenv("TERM")
+	}
+	if term != "" {
+		env = append(env, "TERM="+term)
+	}
+	c.Env = env
+	return c.Run()
+}
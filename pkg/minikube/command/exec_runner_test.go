@@ -0,0 +1,172 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fileAsset is a minimal assets.CopyableFile backed by an in-memory
+// string, for exercising ExecRunner.Copy/Remove against a real
+// filesystem without a real asset.
+type fileAsset struct {
+	*strings.Reader
+	dir, name, perm string
+}
+
+func newFileAsset(content, dir, name, perm string) *fileAsset {
+	return &fileAsset{Reader: strings.NewReader(content), dir: dir, name: name, perm: perm}
+}
+
+func (f *fileAsset) Close() error                   { return nil }
+func (f *fileAsset) GetLength() int                 { return f.Reader.Len() }
+func (f *fileAsset) GetAssetName() string           { return f.name }
+func (f *fileAsset) GetTargetDir() string           { return f.dir }
+func (f *fileAsset) GetTargetName() string          { return f.name }
+func (f *fileAsset) GetPermissions() string         { return f.perm }
+func (f *fileAsset) GetModTime() (time.Time, error) { return time.Time{}, nil }
+
+func TestExecRunnerRun(t *testing.T) {
+	r := NewExecRunner()
+	if err := r.Run("true"); err != nil {
+		t.Errorf("Run(true) = %v, want nil", err)
+	}
+
+	err := r.Run("exit 7")
+	if err == nil {
+		t.Fatal("Run(exit 7): expected an error")
+	}
+	if !strings.Contains(err.Error(), "exit 7") {
+		t.Errorf("Run(exit 7) error = %q, want it to name the failed command", err)
+	}
+}
+
+func TestExecRunnerRunContext(t *testing.T) {
+	r := NewExecRunner()
+	if err := r.RunContext(context.Background(), "true"); err != nil {
+		t.Errorf("RunContext(true) = %v, want nil", err)
+	}
+
+	err := r.RunContext(context.Background(), "exit 7")
+	if err == nil {
+		t.Fatal("RunContext(exit 7): expected an error")
+	}
+	if !strings.Contains(err.Error(), "exit 7") {
+		t.Errorf("RunContext(exit 7) error = %q, want it to name the failed command", err)
+	}
+}
+
+func TestExecRunnerCombinedOutput(t *testing.T) {
+	r := NewExecRunner()
+	out, err := r.CombinedOutput("echo hello")
+	if err != nil {
+		t.Fatalf("CombinedOutput: %v", err)
+	}
+	if !strings.Contains(out, "hello") {
+		t.Errorf("CombinedOutput output = %q, want it to contain %q", out, "hello")
+	}
+
+	if _, err := r.CombinedOutput("exit 7"); err == nil {
+		t.Error("CombinedOutput(exit 7): expected an error")
+	}
+}
+
+func TestExecRunnerCopy(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "addons")
+	r := NewExecRunner()
+	f := newFileAsset("hello world", dir, "greeting.txt", "0640")
+
+	if err := r.Copy(f); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	dst := filepath.Join(dir, "greeting.txt")
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("reading copied file: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("copied content = %q, want %q", got, "hello world")
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("stat copied file: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("copied file mode = %v, want 0640", info.Mode().Perm())
+	}
+}
+
+func TestExecRunnerRemove(t *testing.T) {
+	dir := t.TempDir()
+	r := NewExecRunner()
+	f := newFileAsset("bye", dir, "gone.txt", "0644")
+
+	if err := r.Copy(f); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	dst := filepath.Join(dir, "gone.txt")
+	if _, err := os.Stat(dst); err != nil {
+		t.Fatalf("file missing after Copy: %v", err)
+	}
+
+	if err := r.Remove(f); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("file still present after Remove, stat err = %v", err)
+	}
+}
+
+func TestExecRunnerShellUsesCallerTERMWhenNotSpecified(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	t.Setenv("TERM", "xterm-caller")
+
+	var stdout bytes.Buffer
+	stdin := strings.NewReader("echo TERM=$TERM\nexit 0\n")
+	if err := NewExecRunner().Shell(stdin, &stdout, io.Discard, "", 0, 0); err != nil {
+		t.Fatalf("Shell: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "TERM=xterm-caller") {
+		t.Errorf("Shell output = %q, want it to preserve the caller's TERM", stdout.String())
+	}
+}
+
+func TestExecRunnerShellOverridesTERMWhenSpecified(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	t.Setenv("TERM", "xterm-caller")
+
+	var stdout bytes.Buffer
+	stdin := strings.NewReader("echo TERM=$TERM\nexit 0\n")
+	if err := NewExecRunner().Shell(stdin, &stdout, io.Discard, "xterm-requested", 0, 0); err != nil {
+		t.Fatalf("Shell: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "TERM=xterm-requested") {
+		t.Errorf("Shell output = %q, want the requested TERM", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "xterm-caller") {
+		t.Errorf("Shell output = %q, want the caller's TERM to be replaced, not appended alongside", stdout.String())
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import "io"
+
+// Interactive is implemented by CommandRunner implementations that can
+// attach an interactive shell, giving callers like "minikube ssh" a
+// uniform experience regardless of whether the cluster host is remote
+// or local.
+type Interactive interface {
+	// Shell attaches an interactive shell, wiring stdin/stdout/stderr
+	// through. term, w and h describe the terminal; each implementation
+	// documents how it uses them and whether it follows later resizes.
+	Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error
+}
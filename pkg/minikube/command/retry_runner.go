@@ -0,0 +1,227 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// Dialer re-establishes the transport underneath a CommandRunner, used
+// by RetryRunner to recover from a dropped connection.
+type Dialer interface {
+	Dial() (CommandRunner, error)
+}
+
+// RetryPolicy controls how a RetryRunner retries failed commands.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// DefaultRetryPolicy is a sensible default for flaky cluster networking.
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, Backoff: 2 * time.Second}
+
+// transientErrors are substrings of errors known to indicate a dropped
+// transport rather than a command that ran and failed on its own.
+var transientErrors = []string{
+	"EOF",
+	"i/o timeout",
+	"handshake failed",
+}
+
+// RetryRunner wraps a CommandRunner, re-dialing and retrying commands
+// that fail with a transient transport error. A non-zero exit code is
+// treated as terminal, since retrying a command that ran and failed
+// would not change the outcome.
+type RetryRunner struct {
+	mu     sync.Mutex
+	inner  CommandRunner
+	dialer Dialer
+	policy RetryPolicy
+}
+
+// NewRetryRunner returns a CommandRunner that retries commands which
+// fail due to a transient transport error, redialing through dialer
+// according to policy.
+func NewRetryRunner(inner CommandRunner, dialer Dialer, policy RetryPolicy) *RetryRunner {
+	return &RetryRunner{inner: inner, dialer: dialer, policy: policy}
+}
+
+// isTransient reports whether err looks like a dropped transport rather
+// than a command that ran and returned non-zero.
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := err.(*CommandError); ok {
+		return false
+	}
+	msg := err.Error()
+	for _, t := range transientErrors {
+		if strings.Contains(msg, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the CommandRunner currently backing r, safe for
+// concurrent use alongside redial.
+func (r *RetryRunner) current() CommandRunner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inner
+}
+
+// redial asks the Dialer for a fresh CommandRunner and swaps it in.
+func (r *RetryRunner) redial() error {
+	c, err := r.dialer.Dial()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.inner = c
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *RetryRunner) retry(f func(CommandRunner) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = f(r.current())
+		if !isTransient(err) || attempt >= r.policy.MaxRetries {
+			return err
+		}
+		glog.Warningf("transient error, retrying (%d/%d): %v", attempt+1, r.policy.MaxRetries, err)
+		time.Sleep(r.policy.Backoff)
+		if dialErr := r.redial(); dialErr != nil {
+			glog.Errorf("redial failed: %v", dialErr)
+		}
+	}
+}
+
+// Run implements CommandRunner.
+func (r *RetryRunner) Run(cmd string) error {
+	return r.retry(func(c CommandRunner) error { return c.Run(cmd) })
+}
+
+// RunContext implements CommandRunner.
+func (r *RetryRunner) RunContext(ctx context.Context, cmd string) error {
+	return r.retry(func(c CommandRunner) error { return c.RunContext(ctx, cmd) })
+}
+
+// CombinedOutput implements CommandRunner.
+func (r *RetryRunner) CombinedOutput(cmd string) (string, error) {
+	var out string
+	err := r.retry(func(c CommandRunner) error {
+		o, err := c.CombinedOutput(cmd)
+		out = o
+		return err
+	})
+	return out, err
+}
+
+// Copy implements CommandRunner.
+func (r *RetryRunner) Copy(f assets.CopyableFile) error {
+	return r.retry(func(c CommandRunner) error { return c.Copy(f) })
+}
+
+// Remove implements CommandRunner.
+func (r *RetryRunner) Remove(f assets.CopyableFile) error {
+	return r.retry(func(c CommandRunner) error { return c.Remove(f) })
+}
+
+var _ ExtendedRunner = (*RetryRunner)(nil)
+
+// RunWithExitCode implements ExtendedRunner, retrying the whole call on a
+// transient transport error just like Run does.
+func (r *RetryRunner) RunWithExitCode(cmd string) (stdout, stderr string, code int, err error) {
+	err = r.retry(func(c CommandRunner) error {
+		e, ok := c.(ExtendedRunner)
+		if !ok {
+			return fmt.Errorf("underlying runner does not support RunWithExitCode")
+		}
+		var rerr error
+		stdout, stderr, code, rerr = e.RunWithExitCode(cmd)
+		return rerr
+	})
+	return stdout, stderr, code, err
+}
+
+// Start implements ExtendedRunner, retrying the start itself on a
+// transient transport error. Once started, the returned RemoteCmd is not
+// retried if the session it's bound to later drops.
+func (r *RetryRunner) Start(cmd string) (rc *RemoteCmd, err error) {
+	err = r.retry(func(c CommandRunner) error {
+		e, ok := c.(ExtendedRunner)
+		if !ok {
+			return fmt.Errorf("underlying runner does not support Start")
+		}
+		var serr error
+		rc, serr = e.Start(cmd)
+		return serr
+	})
+	return rc, err
+}
+
+// Shell implements ExtendedRunner. An interactive shell isn't retried:
+// once it's talking to a terminal there's no sensible way to transparently
+// redial and resume it, so a dropped connection just surfaces as an error.
+func (r *RetryRunner) Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error {
+	e, ok := r.current().(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support Shell")
+	}
+	return e.Shell(stdin, stdout, stderr, term, w, h)
+}
+
+// CopyMany implements ExtendedRunner. It does not delegate to the inner
+// runner's CopyMany and retry the call as a whole: CopyMany aggregates
+// one error per failed file into a multierror, and isTransient can't
+// tell a single transient failure buried in that aggregate from a batch
+// of permanent ones, so retrying on it would redial and re-run every
+// file — including ones that already succeeded — to appease files that
+// will just fail the same way again. Instead, each file is copied (and,
+// on a transient error, retried and redialed) independently through
+// Copy, with the same bounded concurrency CopyMany itself uses; the
+// per-directory mkdir-p coalescing still happens, via the cache Copy
+// shares across calls on the underlying SSHRunner.
+func (r *RetryRunner) CopyMany(files []assets.CopyableFile, concurrency int) error {
+	return copyConcurrently(len(files), concurrency, func(i int) error {
+		return r.Copy(files[i])
+	})
+}
+
+// CopyDir implements ExtendedRunner, retrying on a transient transport
+// error just like Copy does.
+func (r *RetryRunner) CopyDir(localDir, remoteDir string) error {
+	return r.retry(func(c CommandRunner) error {
+		e, ok := c.(ExtendedRunner)
+		if !ok {
+			return fmt.Errorf("underlying runner does not support CopyDir")
+		}
+		return e.CopyDir(localDir, remoteDir)
+	})
+}
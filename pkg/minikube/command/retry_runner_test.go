@@ -0,0 +1,237 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// fakeRunner is a minimal CommandRunner stub for exercising decorators
+// without a real SSH connection.
+type fakeRunner struct {
+	runErr error
+}
+
+func (f *fakeRunner) Run(cmd string) error                             { return f.runErr }
+func (f *fakeRunner) RunContext(ctx context.Context, cmd string) error { return f.runErr }
+func (f *fakeRunner) CombinedOutput(cmd string) (string, error)        { return "", f.runErr }
+func (f *fakeRunner) Copy(a assets.CopyableFile) error                 { return f.runErr }
+func (f *fakeRunner) Remove(a assets.CopyableFile) error               { return f.runErr }
+
+// fakeExtendedRunner additionally implements ExtendedRunner, for
+// exercising the decorators' pass-through of the SSHRunner-only methods.
+type fakeExtendedRunner struct {
+	fakeRunner
+	exitCode int
+}
+
+func (f *fakeExtendedRunner) RunWithExitCode(cmd string) (string, string, int, error) {
+	return "", "", f.exitCode, f.runErr
+}
+func (f *fakeExtendedRunner) Start(cmd string) (*RemoteCmd, error) { return nil, f.runErr }
+func (f *fakeExtendedRunner) Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error {
+	return f.runErr
+}
+func (f *fakeExtendedRunner) CopyMany(files []assets.CopyableFile, concurrency int) error {
+	return f.runErr
+}
+func (f *fakeExtendedRunner) CopyDir(localDir, remoteDir string) error { return f.runErr }
+
+// countingDialer hands out a fresh fakeRunner on every Dial, counting
+// how many times it was asked.
+type countingDialer struct {
+	mu    sync.Mutex
+	dials int
+}
+
+func (d *countingDialer) Dial() (CommandRunner, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials++
+	return &fakeRunner{}, nil
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{fmt.Errorf("EOF"), true},
+		{fmt.Errorf("read: i/o timeout"), true},
+		{fmt.Errorf("ssh: handshake failed: foo"), true},
+		{fmt.Errorf("some other transport error"), false},
+		{&CommandError{ExitCode: 1}, false},
+	}
+	for _, tc := range tests {
+		if got := isTransient(tc.err); got != tc.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryRunnerRetriesTransientErrorsThenSucceeds(t *testing.T) {
+	dials := 0
+	inner := &fakeRunner{runErr: fmt.Errorf("EOF")}
+	r := NewRetryRunner(inner, DialerFunc(func() (CommandRunner, error) {
+		dials++
+		return &fakeRunner{}, nil // the redialed runner works fine
+	}), RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+
+	if err := r.Run("true"); err != nil {
+		t.Fatalf("expected retry to recover via redial, got: %v", err)
+	}
+	if dials != 1 {
+		t.Errorf("expected exactly one redial, got %d", dials)
+	}
+}
+
+func TestRetryRunnerExhaustsRetriesOnPersistentTransientError(t *testing.T) {
+	dials := 0
+	inner := &fakeRunner{runErr: fmt.Errorf("EOF")}
+	r := NewRetryRunner(inner, DialerFunc(func() (CommandRunner, error) {
+		dials++
+		return &fakeRunner{runErr: fmt.Errorf("EOF")}, nil
+	}), RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+
+	err := r.Run("true")
+	if err == nil {
+		t.Fatal("expected the persistent transient error to surface after exhausting retries")
+	}
+	if dials != 2 {
+		t.Errorf("expected 2 redials (one per retry), got %d", dials)
+	}
+}
+
+func TestRetryRunnerDoesNotRetryCommandError(t *testing.T) {
+	dialer := &countingDialer{}
+	inner := &fakeRunner{runErr: &CommandError{ExitCode: 1}}
+	r := NewRetryRunner(inner, dialer, DefaultRetryPolicy)
+
+	if err := r.Run("false"); err == nil {
+		t.Fatal("expected the exit error to surface")
+	}
+	if dialer.dials != 0 {
+		t.Errorf("expected no redial for a terminal exit code, got %d", dialer.dials)
+	}
+}
+
+func TestRetryRunnerConcurrentUseDoesNotRace(t *testing.T) {
+	inner := &fakeRunner{}
+	r := NewRetryRunner(inner, DialerFunc(func() (CommandRunner, error) {
+		return &fakeRunner{}, nil
+	}), RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = r.Run("true")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRetryRunnerPassesThroughExtendedMethods(t *testing.T) {
+	r := NewRetryRunner(&fakeExtendedRunner{exitCode: 7}, DialerFunc(func() (CommandRunner, error) {
+		return &fakeExtendedRunner{}, nil
+	}), DefaultRetryPolicy)
+
+	if _, _, code, err := r.RunWithExitCode("true"); err != nil || code != 7 {
+		t.Errorf("RunWithExitCode = (_, _, %d, %v), want (_, _, 7, nil)", code, err)
+	}
+	if err := r.CopyMany(nil, 1); err != nil {
+		t.Errorf("CopyMany = %v, want nil", err)
+	}
+	if err := r.CopyDir("", ""); err != nil {
+		t.Errorf("CopyDir = %v, want nil", err)
+	}
+}
+
+// perFileCopyRunner fails Copy per-file according to errs, keyed by
+// GetTargetName, and counts how many times each file was attempted.
+type perFileCopyRunner struct {
+	fakeRunner
+	mu     sync.Mutex
+	errs   map[string]error
+	copies map[string]int
+}
+
+func (f *perFileCopyRunner) Copy(file assets.CopyableFile) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.copies == nil {
+		f.copies = map[string]int{}
+	}
+	f.copies[file.GetTargetName()]++
+	return f.errs[file.GetTargetName()]
+}
+
+func TestRetryRunnerCopyManyRetriesPerFileNotWholeBatch(t *testing.T) {
+	inner := &perFileCopyRunner{errs: map[string]error{
+		"permanent.yaml": fmt.Errorf("chmod: permission denied"),
+		"transient.yaml": fmt.Errorf("unexpected EOF"),
+	}}
+	// Redial hands back the same runner: what's under test is whether
+	// each file is retried independently, not the redial itself.
+	r := NewRetryRunner(inner, DialerFunc(func() (CommandRunner, error) { return inner, nil }), RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond})
+
+	files := []assets.CopyableFile{
+		fakeCopyableFile{name: "ok.yaml"},
+		fakeCopyableFile{name: "permanent.yaml"},
+		fakeCopyableFile{name: "transient.yaml"},
+	}
+	err := r.CopyMany(files, 3)
+	if err == nil {
+		t.Fatal("expected an aggregated error describing the permanent and transient failures")
+	}
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	if inner.copies["ok.yaml"] != 1 {
+		t.Errorf("ok.yaml copied %d times, want 1 (it already succeeded, it should not be retried)", inner.copies["ok.yaml"])
+	}
+	if inner.copies["permanent.yaml"] != 1 {
+		t.Errorf("permanent.yaml copied %d times, want 1 (a non-transient error is terminal)", inner.copies["permanent.yaml"])
+	}
+	if inner.copies["transient.yaml"] != 2 {
+		t.Errorf("transient.yaml copied %d times, want 2 (1 initial attempt + 1 retry)", inner.copies["transient.yaml"])
+	}
+}
+
+func TestRetryRunnerExtendedMethodsErrorOnNarrowInner(t *testing.T) {
+	r := NewRetryRunner(&fakeRunner{}, DialerFunc(func() (CommandRunner, error) {
+		return &fakeRunner{}, nil
+	}), RetryPolicy{MaxRetries: 0})
+
+	if _, _, _, err := r.RunWithExitCode("true"); err == nil {
+		t.Fatal("expected an error when the wrapped runner doesn't implement ExtendedRunner")
+	}
+}
+
+// DialerFunc adapts a plain function to the Dialer interface.
+type DialerFunc func() (CommandRunner, error)
+
+func (f DialerFunc) Dial() (CommandRunner, error) { return f() }
@@ -18,15 +18,27 @@ package command
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/golang/glog"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/minikube/pkg/minikube/assets"
 	"k8s.io/minikube/pkg/util"
@@ -36,13 +48,67 @@ import (
 //
 // It implements the CommandRunner interface.
 type SSHRunner struct {
-	c *ssh.Client
+	c        *ssh.Client
+	sftpOnce sync.Once
+	sftpc    *sftp.Client
+	sftpErr  error
+
+	mkdirMu   sync.Mutex
+	mkdirDone map[string]error
 }
 
+var _ ExtendedRunner = (*SSHRunner)(nil)
+
 // NewSSHRunner returns a new SSHRunner that will run commands
 // through the ssh.Client provided.
 func NewSSHRunner(c *ssh.Client) *SSHRunner {
-	return &SSHRunner{c}
+	return &SSHRunner{c: c}
+}
+
+// sftpServerCmd starts the sftp subsystem escalated via sudo, so that
+// writes into root-owned target directories (addon bundles, binaries
+// under /usr/bin, and the like) succeed the same way they did under
+// the old "sudo scp -t" path. sftp.Client has no way to elevate
+// mid-protocol, so the privilege has to be requested when the
+// subsystem process itself is started.
+const sftpServerCmd = "sudo /usr/lib/openssh/sftp-server"
+
+// sftpSession returns a cached SFTP client for this runner, opening it
+// lazily on first use. Callers should fall back to a legacy transfer path
+// if the remote does not offer the sftp subsystem.
+func (s *SSHRunner) sftpSession() (*sftp.Client, error) {
+	s.sftpOnce.Do(func() {
+		sess, err := s.c.NewSession()
+		if err != nil {
+			s.sftpErr = errors.Wrap(err, "NewSession")
+			return
+		}
+		in, err := sess.StdinPipe()
+		if err != nil {
+			sess.Close()
+			s.sftpErr = errors.Wrap(err, "StdinPipe")
+			return
+		}
+		out, err := sess.StdoutPipe()
+		if err != nil {
+			sess.Close()
+			s.sftpErr = errors.Wrap(err, "StdoutPipe")
+			return
+		}
+		if err := sess.Start(sftpServerCmd); err != nil {
+			sess.Close()
+			s.sftpErr = errors.Wrapf(err, "starting %s", sftpServerCmd)
+			return
+		}
+		client, err := sftp.NewClientPipe(out, in)
+		if err != nil {
+			sess.Close()
+			s.sftpErr = errors.Wrap(err, "opening sftp session")
+			return
+		}
+		s.sftpc = client
+	})
+	return s.sftpc, s.sftpErr
 }
 
 // Remove runs a command to delete a file on the remote.
@@ -98,14 +164,46 @@ func teeSSH(s *ssh.Session, cmd string, outB io.Writer, errB io.Writer) error {
 	return err
 }
 
-// Run starts a command on the remote and waits for it to return.
-func (s *SSHRunner) Run(cmd string) error {
+// CommandError is returned when a remote command ran to completion but
+// exited non-zero or was killed by a signal, as opposed to an SSH
+// transport failure. Callers can type-assert for it to make decisions
+// based on the exit code rather than just a wrapped error string.
+type CommandError struct {
+	Cmd      string
+	ExitCode int
+	Signal   string
+	Stdout   string
+	Stderr   string
+	err      error
+}
+
+// Error implements the error interface.
+func (e *CommandError) Error() string {
+	prefix := ""
+	if e.Cmd != "" {
+		prefix = e.Cmd + ": "
+	}
+	if e.Signal != "" {
+		return fmt.Sprintf("%scommand terminated by signal %s\nstdout: %s\nstderr: %s", prefix, e.Signal, e.Stdout, e.Stderr)
+	}
+	return fmt.Sprintf("%scommand exited %d\nstdout: %s\nstderr: %s", prefix, e.ExitCode, e.Stdout, e.Stderr)
+}
+
+// Cause returns the underlying *ssh.ExitError or *ssh.ExitMissingError,
+// for use with errors.Cause.
+func (e *CommandError) Cause() error {
+	return e.err
+}
+
+// RunWithExitCode starts a command on the remote and waits for it to
+// return, distinguishing "the command ran and returned non-zero" (a
+// *CommandError) from an SSH transport failure.
+func (s *SSHRunner) RunWithExitCode(cmd string) (stdout, stderr string, code int, err error) {
 	glog.Infof("SSH: %s", cmd)
 	sess, err := s.c.NewSession()
 	if err != nil {
-		return errors.Wrap(err, "NewSession")
+		return "", "", -1, errors.Wrap(err, "NewSession")
 	}
-
 	defer func() {
 		if err := sess.Close(); err != nil {
 			if err != io.EOF {
@@ -113,13 +211,141 @@ func (s *SSHRunner) Run(cmd string) error {
 			}
 		}
 	}()
-	var outB bytes.Buffer
-	var errB bytes.Buffer
-	err = teeSSH(sess, cmd, &outB, &errB)
+
+	var outB, errB bytes.Buffer
+	runErr := teeSSH(sess, cmd, &outB, &errB)
+	stdout, stderr = outB.String(), errB.String()
+
+	switch e := runErr.(type) {
+	case nil:
+		return stdout, stderr, 0, nil
+	case *ssh.ExitError:
+		return stdout, stderr, e.ExitStatus(), &CommandError{Cmd: cmd, ExitCode: e.ExitStatus(), Signal: string(e.Signal()), Stdout: stdout, Stderr: stderr, err: e}
+	case *ssh.ExitMissingError:
+		return stdout, stderr, -1, &CommandError{Cmd: cmd, ExitCode: -1, Stdout: stdout, Stderr: stderr, err: e}
+	default:
+		return stdout, stderr, -1, errors.Wrapf(runErr, "command failed: %s", cmd)
+	}
+}
+
+// Run starts a command on the remote and waits for it to return.
+//
+// It is a thin wrapper around RunWithExitCode kept for callers that only
+// care whether the command succeeded. RunWithExitCode's error already
+// carries the command and stdout/stderr (via CommandError's Cmd/Stdout/
+// Stderr fields) or the command alone (the transport-failure branch's
+// "command failed: %s"), so it's returned as-is rather than wrapped
+// again here. A failure to open the session at all is wrapped as
+// "NewSession" and does not name the command.
+func (s *SSHRunner) Run(cmd string) error {
+	_, _, _, err := s.RunWithExitCode(cmd)
+	return err
+}
+
+// RemoteCmd is a handle to a command started asynchronously on the
+// remote via Start.
+type RemoteCmd struct {
+	sess   *ssh.Session
+	cmd    string
+	Stdout io.Reader
+	Stderr io.Reader
+}
+
+// Wait blocks until the remote command exits, returning a *CommandError
+// if it ran to completion but exited non-zero or was signaled.
+func (r *RemoteCmd) Wait() error {
+	defer r.sess.Close()
+	switch e := r.sess.Wait().(type) {
+	case nil:
+		return nil
+	case *ssh.ExitError:
+		return &CommandError{Cmd: r.cmd, ExitCode: e.ExitStatus(), Signal: string(e.Signal()), err: e}
+	case *ssh.ExitMissingError:
+		return &CommandError{Cmd: r.cmd, ExitCode: -1, err: e}
+	default:
+		return errors.Wrap(e, "wait")
+	}
+}
+
+// Signal sends sig to the remote process.
+func (r *RemoteCmd) Signal(sig ssh.Signal) error {
+	return r.sess.Signal(sig)
+}
+
+// Start starts cmd on the remote without waiting for it to complete,
+// returning a handle that can be waited on, signaled, or streamed from.
+func (s *SSHRunner) Start(cmd string) (*RemoteCmd, error) {
+	sess, err := s.c.NewSession()
 	if err != nil {
-		return errors.Wrapf(err, "command failed: %s\nstdout: %s\nstderr: %s", cmd, outB.String(), errB.String())
+		return nil, errors.Wrap(err, "NewSession")
+	}
+
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		sess.Close()
+		return nil, errors.Wrap(err, "stdout")
+	}
+	stderr, err := sess.StderrPipe()
+	if err != nil {
+		sess.Close()
+		return nil, errors.Wrap(err, "stderr")
+	}
+
+	glog.Infof("SSH (async): %s", cmd)
+	if err := sess.Start(cmd); err != nil {
+		sess.Close()
+		return nil, errors.Wrap(err, "start")
+	}
+	return &RemoteCmd{sess: sess, cmd: cmd, Stdout: stdout, Stderr: stderr}, nil
+}
+
+// RunContext starts cmd on the remote and waits for it to return,
+// killing the underlying session if ctx is cancelled first so that
+// callers can bound long-running commands like "apt-get" or
+// "kubeadm init".
+func (s *SSHRunner) RunContext(ctx context.Context, cmd string) error {
+	rcmd, err := s.Start(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Drain stdout/stderr as the command runs, the same way teeSSH does
+	// for Run/CombinedOutput: if nobody reads these pipes, the remote
+	// fills its SSH channel window and blocks on write, so Wait() would
+	// never return for any command that produces real output.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := util.TeePrefix(util.OutPrefix, rcmd.Stdout, ioutil.Discard, glog.Infof); err != nil {
+			glog.Errorf("tee stdout: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := util.TeePrefix(util.ErrPrefix, rcmd.Stderr, ioutil.Discard, glog.Infof); err != nil {
+			glog.Errorf("tee stderr: %v", err)
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		err := rcmd.Wait()
+		wg.Wait()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if err := rcmd.Signal(ssh.SIGKILL); err != nil {
+			glog.Warningf("signal: %v", err)
+		}
+		rcmd.sess.Close()
+		<-done
+		return ctx.Err()
 	}
-	return nil
 }
 
 // CombinedOutputTo runs the command and stores both command
@@ -152,8 +378,294 @@ func (s *SSHRunner) CombinedOutput(cmd string) (string, error) {
 	return out, nil
 }
 
-// Copy copies a file to the remote over SSH.
+// Copy copies a file to the remote over SSH, preferring a resumable
+// SFTP-backed transfer and falling back to legacy SCP if the remote
+// does not offer the sftp subsystem.
 func (s *SSHRunner) Copy(f assets.CopyableFile) error {
+	return s.CopyRecursive(f)
+}
+
+// CopyRecursive copies a file to the remote over SFTP, creating any
+// missing target directories server-side and resuming a previously
+// interrupted transfer rather than starting over.
+func (s *SSHRunner) CopyRecursive(f assets.CopyableFile) error {
+	sftpc, err := s.sftpSession()
+	if err != nil {
+		glog.Infof("sftp subsystem unavailable, falling back to scp: %v", err)
+		return s.copySCP(f)
+	}
+
+	dst := path.Join(f.GetTargetDir(), f.GetTargetName())
+	if err := s.ensureRemoteDir(sftpc, f.GetTargetDir()); err != nil {
+		return err
+	}
+
+	if err := s.transfer(sftpc, f, dst, false); err != nil {
+		return err
+	}
+	if err := s.verifyChecksum(f, dst); err != nil {
+		if _, seekable := f.(io.Seeker); !seekable {
+			return err
+		}
+		// A resumed transfer trusts the remote file's size alone, which
+		// can't tell "already fully uploaded" apart from "stale remote
+		// file that happens to be the same length". If the checksum says
+		// the bytes don't match, the only way to recover is to stop
+		// trusting the remote file at all and re-upload it from scratch.
+		glog.Warningf("%s: checksum mismatch after resumed transfer, retrying with a full re-upload: %v", f.GetTargetName(), err)
+		if err := s.transfer(sftpc, f, dst, true); err != nil {
+			return err
+		}
+		return s.verifyChecksum(f, dst)
+	}
+	return nil
+}
+
+// transfer writes f to dst over sftpc, resuming a previously interrupted
+// transfer by stat'ing dst and seeking both sides to its current size,
+// unless forceFull is set, in which case it always re-uploads from byte 0
+// (used after a checksum mismatch proves the remote bytes can't be trusted).
+func (s *SSHRunner) transfer(sftpc *sftp.Client, f assets.CopyableFile, dst string, forceFull bool) error {
+	var existing int64
+	if !forceFull {
+		if fi, err := sftpc.Stat(dst); err == nil {
+			existing = fi.Size()
+		}
+	}
+	local, seekable := f.(io.Seeker)
+	offset := resumeOffset(existing, seekable, int64(f.GetLength()))
+
+	mode := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		mode |= os.O_TRUNC
+	}
+	rf, err := sftpc.OpenFile(dst, mode)
+	if err != nil {
+		return errors.Wrapf(err, "open %s", dst)
+	}
+	defer rf.Close()
+
+	if offset > 0 {
+		glog.Infof("%s: resuming transfer at byte %d", f.GetTargetName(), offset)
+		if _, err := local.Seek(offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "seeking local asset")
+		}
+		if _, err := rf.Seek(offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "seeking remote asset")
+		}
+	} else if seekable {
+		// The local asset may already be positioned mid- or end-of-file
+		// (e.g. a prior checksum verification read it to EOF), so make
+		// sure a from-scratch transfer actually starts at byte 0.
+		if _, err := local.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrap(err, "seeking local asset")
+		}
+	}
+
+	glog.Infof("Transferring %d bytes to %s", f.GetLength(), dst)
+	copied, err := io.Copy(rf, f)
+	if err != nil {
+		return errors.Wrap(err, "io.Copy")
+	}
+	if total := offset + copied; total != int64(f.GetLength()) {
+		return fmt.Errorf("%s: expected to transfer %d bytes, but transferred %d", f.GetTargetName(), f.GetLength(), total)
+	}
+
+	perm, err := strconv.ParseUint(f.GetPermissions(), 8, 32)
+	if err != nil {
+		return errors.Wrapf(err, "parsing permissions %q", f.GetPermissions())
+	}
+	if err := sftpc.Chmod(dst, os.FileMode(perm)); err != nil {
+		return errors.Wrapf(err, "chmod %s", dst)
+	}
+	return nil
+}
+
+// ensureRemoteDir creates dir on the remote via sftpc.MkdirAll, caching
+// the outcome per runner so that repeated copies into the same directory
+// (e.g. from CopyMany, or CopyDir walking a tree) only pay for one mkdir
+// round trip each, not one per file.
+func (s *SSHRunner) ensureRemoteDir(sftpc *sftp.Client, dir string) error {
+	s.mkdirMu.Lock()
+	defer s.mkdirMu.Unlock()
+	if s.mkdirDone == nil {
+		s.mkdirDone = map[string]error{}
+	}
+	if err, done := s.mkdirDone[dir]; done {
+		return err
+	}
+	err := sftpc.MkdirAll(dir)
+	if err != nil {
+		err = errors.Wrapf(err, "mkdir -p %s", dir)
+	}
+	s.mkdirDone[dir] = err
+	return err
+}
+
+// resumeOffset decides how many bytes of an already-present remote file
+// can be trusted and skipped: none, unless the local asset can seek to
+// resume from partway through, and the remote file isn't larger than
+// the asset is ever going to be (a stale or unrelated leftover).
+func resumeOffset(existing int64, seekable bool, length int64) int64 {
+	if !seekable || existing <= 0 || existing > length {
+		return 0
+	}
+	return existing
+}
+
+// CopyDir recursively copies the contents of localDir to remoteDir over
+// SFTP, preserving the directory structure.
+func (s *SSHRunner) CopyDir(localDir, remoteDir string) error {
+	return filepath.Walk(localDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return errors.Wrapf(err, "relativizing %s", p)
+		}
+		targetDir := path.Join(remoteDir, filepath.ToSlash(filepath.Dir(rel)))
+		perms := fmt.Sprintf("%04o", info.Mode().Perm())
+		f, err := assets.NewFileAsset(p, targetDir, info.Name(), perms)
+		if err != nil {
+			return errors.Wrapf(err, "creating asset for %s", p)
+		}
+		return s.CopyRecursive(f)
+	})
+}
+
+// verifyChecksum compares a sha256 of the local asset against a remote
+// sha256sum of dst, as a fallback check since the sftp subsystem has no
+// built-in integrity verification.
+func (s *SSHRunner) verifyChecksum(f assets.CopyableFile, dst string) error {
+	local, seekable := f.(io.Seeker)
+	if !seekable {
+		return nil
+	}
+	if _, err := local.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "seeking local asset for checksum")
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.Wrap(err, "hashing local asset")
+	}
+	localSum := hex.EncodeToString(h.Sum(nil))
+
+	out, err := s.CombinedOutput(fmt.Sprintf("sha256sum %s", dst))
+	if err != nil {
+		return errors.Wrap(err, "remote sha256sum")
+	}
+	remoteSum, err := parseSha256sumOutput(out)
+	if err != nil {
+		return err
+	}
+	if localSum != remoteSum {
+		return fmt.Errorf("%s: checksum mismatch after transfer: local %s != remote %s", f.GetTargetName(), localSum, remoteSum)
+	}
+	return nil
+}
+
+// parseSha256sumOutput extracts the checksum from the output of the
+// coreutils "sha256sum" command, e.g. "<sum>  <path>\n".
+func parseSha256sumOutput(out string) (string, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected sha256sum output: %q", out)
+	}
+	return fields[0], nil
+}
+
+// CopyMany copies multiple files to the remote, running up to
+// concurrency transfers at once over the one sudo-started SFTP session
+// cached on s (so each is escalated via sudo the same way a single Copy
+// is). It coalesces
+// "mkdir -p" into one call per unique target directory — and records
+// each directory as already created so the per-file CopyRecursive calls
+// below don't each pay for their own redundant sftp MkdirAll — and keeps
+// copying the remaining files even if some fail, returning a multi-error
+// describing every failure.
+func (s *SSHRunner) CopyMany(files []assets.CopyableFile, concurrency int) error {
+	dirs := map[string]bool{}
+	for _, f := range files {
+		dirs[f.GetTargetDir()] = true
+	}
+
+	// A directory that fails to create only dooms the files destined for
+	// it, not the rest of the batch, in keeping with CopyMany's "keep
+	// copying the remaining files even if some fail" contract.
+	var errs *multierror.Error
+	failedDirs := map[string]bool{}
+	s.mkdirMu.Lock()
+	if s.mkdirDone == nil {
+		s.mkdirDone = map[string]error{}
+	}
+	for dir := range dirs {
+		err := s.Run(fmt.Sprintf("sudo mkdir -p %s", dir))
+		if err != nil {
+			err = errors.Wrapf(err, "mkdir -p %s", dir)
+			errs = multierror.Append(errs, err)
+			failedDirs[dir] = true
+		}
+		s.mkdirDone[dir] = err
+	}
+	s.mkdirMu.Unlock()
+
+	copyErr := copyConcurrently(len(files), concurrency, func(i int) error {
+		f := files[i]
+		if failedDirs[f.GetTargetDir()] {
+			return fmt.Errorf("skipping %s: mkdir -p %s failed", f.GetTargetName(), f.GetTargetDir())
+		}
+		if err := s.Copy(f); err != nil {
+			return errors.Wrapf(err, "copying %s", f.GetTargetName())
+		}
+		return nil
+	})
+	if copyErr != nil {
+		errs = multierror.Append(errs, copyErr)
+	}
+	return errs.ErrorOrNil()
+}
+
+// copyConcurrently runs do(0), do(1), ..., do(n-1), at most concurrency
+// of them in flight at once, and aggregates every returned error into a
+// multi-error instead of aborting the rest of the batch on the first
+// failure.
+func copyConcurrently(n, concurrency int, do func(i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var g errgroup.Group
+	sem := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	var errs *multierror.Error
+
+	for i := 0; i < n; i++ {
+		i := i
+		sem <- struct{}{}
+		g.Go(func() error {
+			defer func() { <-sem }()
+			if err := do(i); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	// g.Wait never returns an error: failures are collected into errs above
+	// so that one bad file doesn't abort the rest of the batch.
+	_ = g.Wait()
+	return errs.ErrorOrNil()
+}
+
+// copySCP copies a file to the remote over SSH using the legacy scp
+// protocol. It is used only when the remote does not offer an sftp
+// subsystem.
+func (s *SSHRunner) copySCP(f assets.CopyableFile) error {
 	sess, err := s.c.NewSession()
 	if err != nil {
 		return errors.Wrap(err, "NewSession")
@@ -199,3 +711,75 @@ func (s *SSHRunner) Copy(f assets.CopyableFile) error {
 	}
 	return g.Wait()
 }
+
+// Shell attaches an interactive PTY-backed shell on the remote, wiring
+// stdin/stdout/stderr through and resizing the remote PTY to stdin's
+// current terminal size (falling back to w x h if that can't be
+// queried) whenever the local terminal receives SIGWINCH.
+//
+// It implements command.Interactive.
+func (s *SSHRunner) Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error {
+	sess, err := s.c.NewSession()
+	if err != nil {
+		return errors.Wrap(err, "NewSession")
+	}
+	defer sess.Close()
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.ISIG:          1,
+		ssh.ICANON:        1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if term == "" {
+		term = "xterm"
+	}
+	if err := sess.RequestPty(term, h, w, modes); err != nil {
+		return errors.Wrap(err, "request pty")
+	}
+
+	sess.Stdin = stdin
+	sess.Stdout = stdout
+	sess.Stderr = stderr
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGWINCH)
+	defer signal.Stop(sigc)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-sigc:
+				cw, ch := terminalSize(stdin, w, h)
+				if err := sess.WindowChange(ch, cw); err != nil {
+					glog.Warningf("window change: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := sess.Shell(); err != nil {
+		return errors.Wrap(err, "shell")
+	}
+	return sess.Wait()
+}
+
+// terminalSize returns the current size of stdin's controlling
+// terminal, or fallbackW/fallbackH if stdin isn't backed by one (or
+// its size can't be queried) so that a resize signal never regresses
+// to a smaller, stale size.
+func terminalSize(stdin io.Reader, fallbackW, fallbackH int) (w, h int) {
+	f, ok := stdin.(*os.File)
+	if !ok {
+		return fallbackW, fallbackH
+	}
+	w, h, err := terminal.GetSize(int(f.Fd()))
+	if err != nil {
+		return fallbackW, fallbackH
+	}
+	return w, h
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCommandErrorEmbedsOutputOnce(t *testing.T) {
+	e := &CommandError{ExitCode: 1, Stdout: "stdout: marker", Stderr: "stderr: marker"}
+	msg := e.Error()
+	if n := strings.Count(msg, "stdout: marker"); n != 1 {
+		t.Errorf("CommandError.Error() embedded stdout %d times, want 1: %q", n, msg)
+	}
+	if n := strings.Count(msg, "stderr: marker"); n != 1 {
+		t.Errorf("CommandError.Error() embedded stderr %d times, want 1: %q", n, msg)
+	}
+}
+
+func TestCommandErrorNamesTheCommand(t *testing.T) {
+	e := &CommandError{Cmd: "kubeadm init", ExitCode: 1}
+	if msg := e.Error(); !strings.Contains(msg, "kubeadm init") {
+		t.Errorf("CommandError.Error() = %q, want it to name the failed command", msg)
+	}
+}
+
+func TestTerminalSizeFallsBackForNonFileStdin(t *testing.T) {
+	w, h := terminalSize(strings.NewReader(""), 80, 24)
+	if w != 80 || h != 24 {
+		t.Errorf("terminalSize with a non-*os.File stdin = (%d, %d), want the (80, 24) fallback", w, h)
+	}
+}
+
+func TestResumeOffset(t *testing.T) {
+	tests := []struct {
+		description string
+		existing    int64
+		seekable    bool
+		length      int64
+		want        int64
+	}{
+		{"no remote file", 0, true, 100, 0},
+		{"partial remote file, seekable", 40, true, 100, 40},
+		{"partial remote file, not seekable", 40, false, 100, 0},
+		{"remote file already complete", 100, true, 100, 100},
+		{"stale remote file larger than asset", 200, true, 100, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := resumeOffset(tc.existing, tc.seekable, tc.length); got != tc.want {
+				t.Errorf("resumeOffset(%d, %v, %d) = %d, want %d", tc.existing, tc.seekable, tc.length, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSha256sumOutput(t *testing.T) {
+	tests := []struct {
+		description string
+		out         string
+		want        string
+		wantErr     bool
+	}{
+		{"typical coreutils output", "deadbeef  /tmp/foo\n", "deadbeef", false},
+		{"extra whitespace", "  deadbeef   /tmp/foo  ", "deadbeef", false},
+		{"empty output", "", "", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := parseSha256sumOutput(tc.out)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseSha256sumOutput(%q): expected error, got nil", tc.out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSha256sumOutput(%q): unexpected error: %v", tc.out, err)
+			}
+			if got != tc.want {
+				t.Errorf("parseSha256sumOutput(%q) = %q, want %q", tc.out, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCopyConcurrentlyAggregatesErrors(t *testing.T) {
+	err := copyConcurrently(5, 2, func(i int) error {
+		if i%2 == 0 {
+			return fmt.Errorf("item %d failed", i)
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an aggregated error, got nil")
+	}
+	for _, i := range []int{0, 2, 4} {
+		want := fmt.Sprintf("item %d failed", i)
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected aggregated error to contain %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestCopyConcurrentlyRunsEveryItemDespiteFailures(t *testing.T) {
+	var ran int32
+	err := copyConcurrently(10, 3, func(i int) error {
+		atomic.AddInt32(&ran, 1)
+		if i == 0 {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	})
+	if ran != 10 {
+		t.Errorf("expected all 10 items to run, only %d did", ran)
+	}
+	if err == nil {
+		t.Error("expected the single failure to surface as an error")
+	}
+}
+
+func TestCopyConcurrentlyBoundsInFlight(t *testing.T) {
+	const concurrency = 3
+	var inFlight, maxInFlight int32
+	_ = copyConcurrently(20, concurrency, func(i int) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&inFlight, -1)
+		return nil
+	})
+	if maxInFlight > concurrency {
+		t.Errorf("observed %d items in flight at once, want at most %d", maxInFlight, concurrency)
+	}
+}
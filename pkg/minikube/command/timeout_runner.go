@@ -0,0 +1,192 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// TimeoutRunner wraps a CommandRunner, bounding every command run
+// through it to d.
+type TimeoutRunner struct {
+	inner CommandRunner
+	d     time.Duration
+}
+
+// NewTimeoutRunner returns a CommandRunner that aborts any command
+// taking longer than d.
+func NewTimeoutRunner(inner CommandRunner, d time.Duration) *TimeoutRunner {
+	return &TimeoutRunner{inner: inner, d: d}
+}
+
+// Run implements CommandRunner.
+func (t *TimeoutRunner) Run(cmd string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), t.d)
+	defer cancel()
+	return t.inner.RunContext(ctx, cmd)
+}
+
+// RunContext implements CommandRunner, further bounding ctx to t.d.
+func (t *TimeoutRunner) RunContext(ctx context.Context, cmd string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.inner.RunContext(ctx, cmd)
+}
+
+// CombinedOutput implements CommandRunner. Copy/Remove/CombinedOutput
+// take no context, so inner work can't actually be aborted the way
+// RunContext can abort a command mid-flight; the call still races
+// against the timeout so callers get a bounded wait either way.
+func (t *TimeoutRunner) CombinedOutput(cmd string) (string, error) {
+	type result struct {
+		out string
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		out, err := t.inner.CombinedOutput(cmd)
+		ch <- result{out, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.out, r.err
+	case <-time.After(t.d):
+		return "", fmt.Errorf("command timed out after %s: %s", t.d, cmd)
+	}
+}
+
+// Copy implements CommandRunner.
+func (t *TimeoutRunner) Copy(f assets.CopyableFile) error {
+	ch := make(chan error, 1)
+	go func() { ch <- t.inner.Copy(f) }()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(t.d):
+		return fmt.Errorf("copy timed out after %s", t.d)
+	}
+}
+
+// Remove implements CommandRunner.
+func (t *TimeoutRunner) Remove(f assets.CopyableFile) error {
+	ch := make(chan error, 1)
+	go func() { ch <- t.inner.Remove(f) }()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(t.d):
+		return fmt.Errorf("remove timed out after %s", t.d)
+	}
+}
+
+var _ ExtendedRunner = (*TimeoutRunner)(nil)
+
+// RunWithExitCode implements ExtendedRunner, bounding it to t.d like Run.
+func (t *TimeoutRunner) RunWithExitCode(cmd string) (stdout, stderr string, code int, err error) {
+	e, ok := t.inner.(ExtendedRunner)
+	if !ok {
+		return "", "", 0, fmt.Errorf("underlying runner does not support RunWithExitCode")
+	}
+	type result struct {
+		stdout, stderr string
+		code           int
+		err            error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		so, se, c, rerr := e.RunWithExitCode(cmd)
+		ch <- result{so, se, c, rerr}
+	}()
+	select {
+	case r := <-ch:
+		return r.stdout, r.stderr, r.code, r.err
+	case <-time.After(t.d):
+		return "", "", 0, fmt.Errorf("command timed out after %s: %s", t.d, cmd)
+	}
+}
+
+// Start implements ExtendedRunner, bounding how long starting cmd may
+// take. It does not bound how long the returned RemoteCmd then runs for.
+func (t *TimeoutRunner) Start(cmd string) (*RemoteCmd, error) {
+	e, ok := t.inner.(ExtendedRunner)
+	if !ok {
+		return nil, fmt.Errorf("underlying runner does not support Start")
+	}
+	type result struct {
+		rc  *RemoteCmd
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		rc, err := e.Start(cmd)
+		ch <- result{rc, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.rc, r.err
+	case <-time.After(t.d):
+		return nil, fmt.Errorf("start timed out after %s: %s", t.d, cmd)
+	}
+}
+
+// Shell implements ExtendedRunner. An interactive shell is deliberately
+// not bounded by t.d: a legitimate session can sit idle far longer than
+// any sensible command timeout, so it's passed straight through.
+func (t *TimeoutRunner) Shell(stdin io.Reader, stdout, stderr io.Writer, term string, w, h int) error {
+	e, ok := t.inner.(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support Shell")
+	}
+	return e.Shell(stdin, stdout, stderr, term, w, h)
+}
+
+// CopyMany implements ExtendedRunner, bounding the whole batch to t.d.
+func (t *TimeoutRunner) CopyMany(files []assets.CopyableFile, concurrency int) error {
+	e, ok := t.inner.(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support CopyMany")
+	}
+	ch := make(chan error, 1)
+	go func() { ch <- e.CopyMany(files, concurrency) }()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(t.d):
+		return fmt.Errorf("copy timed out after %s", t.d)
+	}
+}
+
+// CopyDir implements ExtendedRunner, bounding it to t.d like Copy.
+func (t *TimeoutRunner) CopyDir(localDir, remoteDir string) error {
+	e, ok := t.inner.(ExtendedRunner)
+	if !ok {
+		return fmt.Errorf("underlying runner does not support CopyDir")
+	}
+	ch := make(chan error, 1)
+	go func() { ch <- e.CopyDir(localDir, remoteDir) }()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(t.d):
+		return fmt.Errorf("copy timed out after %s: %s -> %s", t.d, localDir, remoteDir)
+	}
+}
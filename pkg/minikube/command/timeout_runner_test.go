@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/minikube/pkg/minikube/assets"
+)
+
+// slowRunner blocks for d before returning, to exercise TimeoutRunner's
+// bound on every method, not just Run/RunContext.
+type slowRunner struct {
+	d time.Duration
+}
+
+func (s *slowRunner) Run(cmd string) error { time.Sleep(s.d); return nil }
+func (s *slowRunner) RunContext(ctx context.Context, cmd string) error {
+	select {
+	case <-time.After(s.d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+func (s *slowRunner) CombinedOutput(cmd string) (string, error) { time.Sleep(s.d); return "", nil }
+func (s *slowRunner) Copy(f assets.CopyableFile) error          { time.Sleep(s.d); return nil }
+func (s *slowRunner) Remove(f assets.CopyableFile) error        { time.Sleep(s.d); return nil }
+
+func TestTimeoutRunnerBoundsRunContext(t *testing.T) {
+	r := NewTimeoutRunner(&slowRunner{d: time.Hour}, 10*time.Millisecond)
+	if err := r.Run("sleep"); err == nil {
+		t.Fatal("expected Run to time out, got nil error")
+	}
+}
+
+func TestTimeoutRunnerBoundsCombinedOutput(t *testing.T) {
+	r := NewTimeoutRunner(&slowRunner{d: time.Hour}, 10*time.Millisecond)
+	if _, err := r.CombinedOutput("sleep"); err == nil {
+		t.Fatal("expected CombinedOutput to time out, got nil error")
+	}
+}
+
+func TestTimeoutRunnerBoundsCopyAndRemove(t *testing.T) {
+	r := NewTimeoutRunner(&slowRunner{d: time.Hour}, 10*time.Millisecond)
+	if err := r.Copy(nil); err == nil {
+		t.Fatal("expected Copy to time out, got nil error")
+	}
+	if err := r.Remove(nil); err == nil {
+		t.Fatal("expected Remove to time out, got nil error")
+	}
+}
+
+func TestTimeoutRunnerPassesThroughFastCommands(t *testing.T) {
+	r := NewTimeoutRunner(&slowRunner{d: time.Millisecond}, time.Hour)
+	if err := r.Run("true"); err != nil {
+		t.Fatalf("expected a fast command to succeed, got: %v", err)
+	}
+}
+
+func TestTimeoutRunnerPassesThroughExtendedMethods(t *testing.T) {
+	r := NewTimeoutRunner(&fakeExtendedRunner{exitCode: 3}, time.Hour)
+	if _, _, code, err := r.RunWithExitCode("true"); err != nil || code != 3 {
+		t.Errorf("RunWithExitCode = (_, _, %d, %v), want (_, _, 3, nil)", code, err)
+	}
+	if err := r.CopyMany(nil, 1); err != nil {
+		t.Errorf("CopyMany = %v, want nil", err)
+	}
+	if err := r.CopyDir("", ""); err != nil {
+		t.Errorf("CopyDir = %v, want nil", err)
+	}
+}
+
+func TestTimeoutRunnerExtendedMethodsErrorOnNarrowInner(t *testing.T) {
+	r := NewTimeoutRunner(&fakeRunner{}, time.Hour)
+	if _, _, _, err := r.RunWithExitCode("true"); err == nil {
+		t.Fatal("expected an error when the wrapped runner doesn't implement ExtendedRunner")
+	}
+}